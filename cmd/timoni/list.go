@@ -18,12 +18,24 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/yaml"
 
 	"github.com/stefanprodan/timoni/internal/runtime"
 )
@@ -40,6 +52,18 @@ var listCmd = &cobra.Command{
 
   # List all instances on a cluster subject to a certain bundle
   timoni ls -A --bundle podinfo
+
+  # List instances matching a label selector
+  timoni ls -A -l app.kubernetes.io/name=podinfo
+
+  # List instances matching a field selector
+  timoni ls -A --field-selector module.version=6.6.0
+
+  # List instance names only, suitable for scripting
+  timoni ls -A -o name
+
+  # Watch instances for changes, printing incremental updates
+  timoni ls -A -w -o json
 `,
 	RunE: runListCmd,
 }
@@ -47,6 +71,10 @@ var listCmd = &cobra.Command{
 type listFlags struct {
 	allNamespaces bool
 	bundleName    string
+	output        string
+	selector      string
+	fieldSelector string
+	watch         bool
 }
 
 var listArgs listFlags
@@ -56,6 +84,14 @@ func init() {
 		"List the requested object(s) across all namespaces.")
 	listCmd.Flags().StringVarP(&listArgs.bundleName, "bundle", "", "",
 		"List the requested object(s) subject to a certain bundle.")
+	listCmd.Flags().StringVarP(&listArgs.output, "output", "o", "",
+		"The format in which the output should be printed. Can be 'yaml', 'json', 'wide' or 'name'.")
+	listCmd.Flags().StringVarP(&listArgs.selector, "selector", "l", "",
+		"Filter the requested object(s) by label selector.")
+	listCmd.Flags().StringVar(&listArgs.fieldSelector, "field-selector", "",
+		"Filter the requested object(s) by field selector, e.g. 'module.repository=...,module.version=...'.")
+	listCmd.Flags().BoolVarP(&listArgs.watch, "watch", "w", false,
+		"After listing the instances, watch for changes and print them as they happen.")
 
 	rootCmd.AddCommand(listCmd)
 }
@@ -66,34 +102,40 @@ func runListCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	var rows [][]string
-	for _, inv := range instances {
-		row := []string{}
-		if listArgs.allNamespaces {
-			row = []string{
-				inv.Name,
-				inv.Namespace,
-				inv.Module.Repository,
-				inv.Module.Version,
-				inv.LastTransitionTime,
-				printOrPass(inv.Labels[apiv1.BundleNameLabelKey]),
-			}
-		} else {
-			row = []string{
-				inv.Name,
-				inv.Module.Repository,
-				inv.Module.Version,
-				inv.LastTransitionTime,
-				printOrPass(inv.Labels[apiv1.BundleNameLabelKey]),
-			}
+	instances, err = filterInstances(instances, listArgs.selector, listArgs.fieldSelector)
+	if err != nil {
+		return err
+	}
+
+	writer := rootCmd.OutOrStdout()
+
+	switch listArgs.output {
+	case "json":
+		if err := printJSON(writer, instances); err != nil {
+			return err
 		}
-		rows = append(rows, row)
+	case "yaml":
+		if err := printYAML(writer, instances); err != nil {
+			return err
+		}
+	case "name":
+		if err := printNames(writer, instances); err != nil {
+			return err
+		}
+	case "wide":
+		if err := printInstancesTable(writer, instances, true); err != nil {
+			return err
+		}
+	case "":
+		if err := printInstancesTable(writer, instances, false); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of: json, yaml, wide, name", listArgs.output)
 	}
 
-	if listArgs.allNamespaces {
-		printTable(rootCmd.OutOrStdout(), []string{"name", "namespace", "module", "version", "last applied", "bundle"}, rows)
-	} else {
-		printTable(rootCmd.OutOrStdout(), []string{"name", "module", "version", "last applied", "bundle"}, rows)
+	if listArgs.watch {
+		return watchInstances(writer)
 	}
 
 	return nil
@@ -118,6 +160,307 @@ func listInstancesFromFlags() ([]*apiv1.Instance, error) {
 	return iStorage.List(ctx, ns, listArgs.bundleName)
 }
 
+// watchInstances opens a watch on the inventory ConfigMaps matching the
+// --all-namespaces/--bundle flags and prints each ADDED/MODIFIED/DELETED
+// event as it happens, until the context is cancelled by SIGINT/SIGTERM or
+// the watch returns an unrecoverable error. A watch whose resourceVersion
+// has expired is transparently restarted.
+func watchInstances(writer io.Writer) error {
+	sm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+	iStorage := runtime.NewStorageManager(sm)
+
+	ns := *kubeconfigArgs.Namespace
+	if listArgs.allNamespaces {
+		ns = ""
+	}
+
+	sel, fieldReqs, err := parseSelectors(listArgs.selector, listArgs.fieldSelector)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for {
+		w, err := iStorage.Watch(ctx, ns, listArgs.bundleName)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("unable to watch instances: %w", err)
+		}
+
+		err = consumeInstanceWatch(ctx, writer, w, sel, fieldReqs)
+		w.Stop()
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, errWatchClosed):
+			continue
+		case apierrors.IsResourceExpired(err):
+			continue
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// errWatchClosed is returned by consumeInstanceWatch when the result
+// channel closes without a watch.Error event. This can happen for
+// reasons unrelated to resourceVersion expiry, e.g. an idle proxy
+// timeout, so the caller always restarts the watch on it rather than
+// relying on apierrors.IsResourceExpired.
+var errWatchClosed = errors.New("watch channel closed")
+
+// consumeInstanceWatch prints events from w until the context is
+// cancelled or the watch channel closes.
+func consumeInstanceWatch(ctx context.Context, writer io.Writer, w watch.Interface, sel labels.Selector, fieldReqs map[string]string) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return errWatchClosed
+			}
+
+			if event.Type == watch.Error {
+				return apierrors.FromObject(event.Object)
+			}
+
+			inv, err := runtime.InstanceFromObject(event.Object)
+			if err != nil {
+				continue
+			}
+
+			if !matchesSelectors(inv, sel, fieldReqs) {
+				continue
+			}
+
+			if err := printWatchEvent(writer, event.Type, inv); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func printWatchEvent(writer io.Writer, eventType watch.EventType, inv *apiv1.Instance) error {
+	timestamp := time.Now().Format(time.RFC3339)
+
+	if listArgs.output == "json" {
+		b, err := json.Marshal(struct {
+			Timestamp string          `json:"timestamp"`
+			Type      string          `json:"type"`
+			Instance  *apiv1.Instance `json:"instance"`
+		}{timestamp, string(eventType), inv})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(writer, string(b))
+		return err
+	}
+
+	row := []string{timestamp, string(eventType), inv.Name}
+	if listArgs.allNamespaces {
+		row = append(row, inv.Namespace)
+	}
+	row = append(row, inv.Module.Repository, inv.Module.Version, printOrPass(inv.Labels[apiv1.BundleNameLabelKey]))
+
+	printTable(writer, nil, [][]string{row})
+	return nil
+}
+
+// filterInstances narrows down instances to those matching both the label
+// selector and the field selector, when set. An empty selector matches
+// everything.
+func filterInstances(instances []*apiv1.Instance, selector, fieldSelector string) ([]*apiv1.Instance, error) {
+	sel, fieldReqs, err := parseSelectors(selector, fieldSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	if sel == nil && fieldReqs == nil {
+		return instances, nil
+	}
+
+	filtered := make([]*apiv1.Instance, 0, len(instances))
+	for _, inv := range instances {
+		if matchesSelectors(inv, sel, fieldReqs) {
+			filtered = append(filtered, inv)
+		}
+	}
+
+	return filtered, nil
+}
+
+// parseSelectors parses the label and field selector flags, returning nil
+// for either one that is empty.
+func parseSelectors(selector, fieldSelector string) (labels.Selector, map[string]string, error) {
+	var sel labels.Selector
+	if selector != "" {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid selector: %w", err)
+		}
+		sel = s
+	}
+
+	var fieldReqs map[string]string
+	if fieldSelector != "" {
+		fr, err := parseFieldSelector(fieldSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid field selector: %w", err)
+		}
+		fieldReqs = fr
+	}
+
+	return sel, fieldReqs, nil
+}
+
+// matchesSelectors reports whether inv satisfies both sel and fieldReqs.
+// A nil selector matches everything.
+func matchesSelectors(inv *apiv1.Instance, sel labels.Selector, fieldReqs map[string]string) bool {
+	if sel != nil && !sel.Matches(labels.Set(inv.Labels)) {
+		return false
+	}
+	if fieldReqs != nil && !matchesFieldSelector(inv, fieldReqs) {
+		return false
+	}
+	return true
+}
+
+// parseFieldSelector parses a comma-separated list of key=value terms,
+// e.g. "module.repository=ghcr.io/org/app,module.version=1.0.0".
+func parseFieldSelector(selector string) (map[string]string, error) {
+	reqs := make(map[string]string)
+	for _, term := range strings.Split(selector, ",") {
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid field selector term %q, expected key=value", term)
+		}
+		reqs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return reqs, nil
+}
+
+// matchesFieldSelector reports whether the instance satisfies all the
+// given field requirements. Supported fields are name, namespace,
+// module.repository and module.version.
+func matchesFieldSelector(inv *apiv1.Instance, reqs map[string]string) bool {
+	for key, value := range reqs {
+		var actual string
+		switch key {
+		case "name":
+			actual = inv.Name
+		case "namespace":
+			actual = inv.Namespace
+		case "module.repository":
+			actual = inv.Module.Repository
+		case "module.version":
+			actual = inv.Module.Version
+		default:
+			return false
+		}
+		if actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+func printJSON(writer io.Writer, instances []*apiv1.Instance) error {
+	b, err := json.MarshalIndent(instances, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(writer, string(b))
+	return err
+}
+
+func printYAML(writer io.Writer, instances []*apiv1.Instance) error {
+	b, err := yaml.Marshal(instances)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(writer, string(b))
+	return err
+}
+
+func printNames(writer io.Writer, instances []*apiv1.Instance) error {
+	for _, inv := range instances {
+		if listArgs.allNamespaces {
+			fmt.Fprintln(writer, inv.Namespace+"/"+inv.Name)
+		} else {
+			fmt.Fprintln(writer, inv.Name)
+		}
+	}
+	return nil
+}
+
+func printInstancesTable(writer io.Writer, instances []*apiv1.Instance, wide bool) error {
+	var rows [][]string
+	for _, inv := range instances {
+		row := []string{}
+		switch {
+		case listArgs.allNamespaces && wide:
+			row = []string{
+				inv.Name,
+				inv.Namespace,
+				inv.Module.Repository,
+				inv.Module.Version,
+				inv.LastTransitionTime,
+				printOrPass(inv.Labels[apiv1.BundleNameLabelKey]),
+				printOrPass(labels.Set(inv.Labels).String()),
+			}
+		case listArgs.allNamespaces:
+			row = []string{
+				inv.Name,
+				inv.Namespace,
+				inv.Module.Repository,
+				inv.Module.Version,
+				inv.LastTransitionTime,
+				printOrPass(inv.Labels[apiv1.BundleNameLabelKey]),
+			}
+		case wide:
+			row = []string{
+				inv.Name,
+				inv.Module.Repository,
+				inv.Module.Version,
+				inv.LastTransitionTime,
+				printOrPass(inv.Labels[apiv1.BundleNameLabelKey]),
+				printOrPass(labels.Set(inv.Labels).String()),
+			}
+		default:
+			row = []string{
+				inv.Name,
+				inv.Module.Repository,
+				inv.Module.Version,
+				inv.LastTransitionTime,
+				printOrPass(inv.Labels[apiv1.BundleNameLabelKey]),
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	switch {
+	case listArgs.allNamespaces && wide:
+		printTable(writer, []string{"name", "namespace", "module", "version", "last applied", "bundle", "labels"}, rows)
+	case listArgs.allNamespaces:
+		printTable(writer, []string{"name", "namespace", "module", "version", "last applied", "bundle"}, rows)
+	case wide:
+		printTable(writer, []string{"name", "module", "version", "last applied", "bundle", "labels"}, rows)
+	default:
+		printTable(writer, []string{"name", "module", "version", "last applied", "bundle"}, rows)
+	}
+
+	return nil
+}
+
 func printTable(writer io.Writer, header []string, rows [][]string) {
 	table := tablewriter.NewWriter(writer)
 	table.SetHeader(header)
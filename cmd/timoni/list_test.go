@@ -0,0 +1,145 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+func TestParseFieldSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "single term",
+			in:   "module.version=6.6.0",
+			want: map[string]string{"module.version": "6.6.0"},
+		},
+		{
+			name: "multiple terms with spacing",
+			in:   "module.repository=ghcr.io/org/app, module.version = 1.0.0",
+			want: map[string]string{"module.repository": "ghcr.io/org/app", "module.version": "1.0.0"},
+		},
+		{
+			name:    "missing value",
+			in:      "module.version",
+			wantErr: true,
+		},
+		{
+			name:    "empty term",
+			in:      "module.version=1.0.0,",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFieldSelector(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchesFieldSelector(t *testing.T) {
+	inv := &apiv1.Instance{}
+	inv.Name = "podinfo"
+	inv.Namespace = "default"
+	inv.Module.Repository = "ghcr.io/org/app"
+	inv.Module.Version = "6.6.0"
+
+	tests := []struct {
+		name string
+		reqs map[string]string
+		want bool
+	}{
+		{name: "matching name and version", reqs: map[string]string{"name": "podinfo", "module.version": "6.6.0"}, want: true},
+		{name: "mismatching version", reqs: map[string]string{"module.version": "1.0.0"}, want: false},
+		{name: "unsupported field", reqs: map[string]string{"module.unknown": "anything"}, want: false},
+		{name: "empty requirements", reqs: map[string]string{}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesFieldSelector(inv, tt.reqs); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterInstances(t *testing.T) {
+	podinfo := &apiv1.Instance{}
+	podinfo.Name = "podinfo"
+	podinfo.Labels = map[string]string{"app.kubernetes.io/name": "podinfo"}
+	podinfo.Module.Version = "6.6.0"
+
+	redis := &apiv1.Instance{}
+	redis.Name = "redis"
+	redis.Labels = map[string]string{"app.kubernetes.io/name": "redis"}
+	redis.Module.Version = "7.0.0"
+
+	instances := []*apiv1.Instance{podinfo, redis}
+
+	filtered, err := filterInstances(instances, "app.kubernetes.io/name=redis", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Name != "redis" {
+		t.Fatalf("got %v, want only redis", filtered)
+	}
+
+	filtered, err = filterInstances(instances, "", "module.version=6.6.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Name != "podinfo" {
+		t.Fatalf("got %v, want only podinfo", filtered)
+	}
+
+	filtered, err = filterInstances(instances, "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filtered) != len(instances) {
+		t.Fatalf("got %d instances, want %d", len(filtered), len(instances))
+	}
+
+	if _, err := filterInstances(instances, "app.kubernetes.io/name in (", ""); err == nil {
+		t.Fatalf("expected an error for an invalid label selector")
+	}
+}
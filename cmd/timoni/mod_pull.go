@@ -22,10 +22,12 @@ import (
 	"os"
 
 	oci "github.com/fluxcd/pkg/oci/client"
+	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 
 	"github.com/stefanprodan/timoni/internal/engine"
 	"github.com/stefanprodan/timoni/internal/flags"
+	"github.com/stefanprodan/timoni/internal/ociarchive"
 	"github.com/stefanprodan/timoni/internal/signutil"
 )
 
@@ -42,6 +44,21 @@ extract its contents the specified directory.`,
   timoni mod pull oci://ghcr.io/org/manifests/app --version 1.0.0 \
 	--output ./path/to/module \
 	--creds timoni:$GITHUB_TOKEN
+
+  # Pull a module from a local archive, without contacting a registry
+  timoni mod pull oci://ghcr.io/org/manifests/app --version 1.0.0 \
+	--from-archive ./path/to/module.tar \
+	--output ./path/to/module
+
+  # Pull a module signed with Notary v2
+  timoni mod pull oci://ghcr.io/org/manifests/app --version 1.0.0 \
+	--output ./path/to/module \
+	--verify notation
+
+  # Pull a module and require it to match a SLSA provenance attestation policy
+  timoni mod pull oci://ghcr.io/org/manifests/app --version 1.0.0 \
+	--output ./path/to/module \
+	--attestation-policy ./path/to/policy.yaml
 `,
 	RunE: pullCmdRun,
 }
@@ -56,6 +73,8 @@ type pullModFlags struct {
 	certificateIdentityRegexp   string
 	certificateOidcIssuer       string
 	certificateOidcIssuerRegexp string
+	fromArchive                 string
+	attestationPolicy           string
 }
 
 var pullModArgs pullModFlags
@@ -66,7 +85,7 @@ func init() {
 		"The directory path where the module content should be extracted.")
 	pullModCmd.Flags().Var(&pullModArgs.creds, pullModArgs.creds.Type(), pullModArgs.creds.Description())
 	pullModCmd.Flags().StringVar(&pullModArgs.verify, "verify", "",
-		"Verifies the signed module with the specified provvider.")
+		"Verifies the signed module with the specified provider, can be 'cosign' or 'notation'.")
 	pullModCmd.Flags().StringVar(&pullModArgs.cosignKey, "cosign-key", "",
 		"The Cosign public key for verifying the module.")
 	pullModCmd.Flags().StringVar(&pullModArgs.certificateIdentity, "certificate-identity", "",
@@ -85,6 +104,12 @@ func init() {
 		"A regular expression alternative to --certificate-oidc-issuer for verifying the Cosign signature.\n"+
 			"Accepts the Go regular expression syntax described at https://golang.org/s/re2syntax.\n"+
 			"Either --certificate-oidc-issuer or --certificate-oidc-issuer-regexp must be set for keyless flows.")
+	pullModCmd.Flags().StringVar(&pullModArgs.fromArchive, "from-archive", "",
+		"Path to a local OCI image layout or docker save tarball to pull the module from,\n"+
+			"instead of reaching out to the container registry.")
+	pullModCmd.Flags().StringVar(&pullModArgs.attestationPolicy, "attestation-policy", "",
+		"Path to a YAML or JSON file with the in-toto/SLSA provenance predicates the module\n"+
+			"must satisfy, e.g. the expected builder identity and source repository.")
 
 	modCmd.AddCommand(pullModCmd)
 }
@@ -97,11 +122,6 @@ func pullCmdRun(cmd *cobra.Command, args []string) error {
 
 	log := LoggerFrom(cmd.Context())
 
-	version := pullModArgs.version.String()
-	if version == "" {
-		version = engine.LatestTag
-	}
-
 	if pullModArgs.output == "" {
 		return fmt.Errorf("invalid output path %s", pullModArgs.output)
 	}
@@ -110,6 +130,22 @@ func pullCmdRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid output path %s", pullModArgs.output)
 	}
 
+	// --from-archive gets the raw (possibly empty) --version value, so
+	// ociarchive.Load can fall back to the archive's single manifest when
+	// no version was specified, instead of defaulting to the
+	// registry-oriented "latest" tag.
+	if pullModArgs.fromArchive != "" {
+		if pullModArgs.attestationPolicy != "" {
+			return fmt.Errorf("--attestation-policy requires contacting the registry and cannot be used with --from-archive")
+		}
+		return pullFromArchive(log, pullModArgs.version.String())
+	}
+
+	version := pullModArgs.version.String()
+	if version == "" {
+		version = engine.LatestTag
+	}
+
 	url, err := oci.ParseArtifactURL(ociURL + ":" + version)
 	if err != nil {
 		return err
@@ -134,6 +170,17 @@ func pullCmdRun(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if pullModArgs.attestationPolicy != "" {
+		policy, err := signutil.LoadAttestationPolicy(pullModArgs.attestationPolicy)
+		if err != nil {
+			return err
+		}
+		if err := signutil.EvaluateAttestationPolicy(log, url, policy, pullModArgs.cosignKey, pullModArgs.certificateIdentity,
+			pullModArgs.certificateIdentityRegexp, pullModArgs.certificateOidcIssuer, pullModArgs.certificateOidcIssuerRegexp); err != nil {
+			return err
+		}
+	}
+
 	spin := StartSpinner("pulling module")
 	_, err = ociClient.Pull(ctx, url, pullModArgs.output)
 	spin.Stop()
@@ -145,3 +192,34 @@ func pullCmdRun(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// pullFromArchive loads the module matching version from a local OCI image
+// layout or docker save tarball, and extracts it the same way a registry
+// pull would, without any network access.
+func pullFromArchive(log logr.Logger, version string) error {
+	manifest, err := ociarchive.Load(pullModArgs.fromArchive, version)
+	if err != nil {
+		return fmt.Errorf("could not load module from archive: %w", err)
+	}
+
+	if pullModArgs.verify != "" {
+		sig, err := manifest.SiblingSignature(pullModArgs.fromArchive)
+		if err != nil {
+			return err
+		}
+		if err := signutil.VerifyArchive(log, pullModArgs.verify, manifest.Digest, sig, pullModArgs.cosignKey); err != nil {
+			return err
+		}
+	}
+
+	spin := StartSpinner("pulling module")
+	err = manifest.Extract(pullModArgs.output)
+	spin.Stop()
+	if err != nil {
+		return fmt.Errorf("could not extract module: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("module extracted to %s", pullModArgs.output))
+
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	oci "github.com/fluxcd/pkg/oci/client"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/timoni/internal/flags"
+	"github.com/stefanprodan/timoni/internal/ociarchive"
+)
+
+var pushModCmd = &cobra.Command{
+	Use:   "push [MODULE PATH] [MODULE URL]",
+	Short: "Push a module version to a container registry",
+	Long: `The push command packages the module as an OCI artifact and pushes it
+to the specified container registry.`,
+	Example: `  # Push a module to Docker Hub using the credentials from '~/.docker/config.json'
+  timoni mod push ./path/to/module oci://docker.io/org/app \
+	--version=1.0.0
+
+  # Push a module to GitHub Container Registry using a GitHub token
+  timoni mod push ./path/to/module oci://ghcr.io/org/manifests/app \
+	--version=1.0.0 \
+	--creds=timoni:$GITHUB_TOKEN
+
+  # Re-push a module from a local archive, without rebuilding it
+  timoni mod push ./path/to/module.tar oci://ghcr.io/org/manifests/app \
+	--version=1.0.0 \
+	--from-archive \
+	--creds=timoni:$GITHUB_TOKEN
+`,
+	RunE: pushCmdRun,
+}
+
+type pushModFlags struct {
+	version     flags.Version
+	creds       flags.Credentials
+	fromArchive bool
+}
+
+var pushModArgs pushModFlags
+
+func init() {
+	pushModCmd.Flags().VarP(&pushModArgs.version, pushModArgs.version.Type(), pushModArgs.version.Shorthand(), pushModArgs.version.Description())
+	pushModCmd.Flags().Var(&pushModArgs.creds, pushModArgs.creds.Type(), pushModArgs.creds.Description())
+	pushModCmd.Flags().BoolVar(&pushModArgs.fromArchive, "from-archive", false,
+		"Treat the module path as a local OCI image layout or docker save tarball\n"+
+			"to push, instead of a module source directory.")
+
+	modCmd.AddCommand(pushModCmd)
+}
+
+func pushCmdRun(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("module path and module URL are required")
+	}
+	modulePath := args[0]
+	ociURL := args[1]
+
+	log := LoggerFrom(cmd.Context())
+
+	version := pushModArgs.version.String()
+	if version == "" {
+		return fmt.Errorf("--version is required")
+	}
+
+	if fs, err := os.Stat(modulePath); err != nil || (!pushModArgs.fromArchive && !fs.IsDir()) {
+		return fmt.Errorf("invalid module path %s", modulePath)
+	}
+
+	url, err := oci.ParseArtifactURL(ociURL + ":" + version)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	ociClient := oci.NewClient(nil)
+
+	if pushModArgs.creds != "" {
+		if err := ociClient.LoginWithCredentials(pushModArgs.creds.String()); err != nil {
+			return fmt.Errorf("could not login with credentials: %w", err)
+		}
+	}
+
+	spin := StartSpinner("pushing module")
+	defer spin.Stop()
+
+	// --from-archive reuses the same Push primitive as a regular module
+	// directory: the archive's layers are extracted to a temporary
+	// directory first, the same way `timoni mod pull --from-archive`
+	// extracts them to the user-specified output.
+	if pushModArgs.fromArchive {
+		manifest, err := ociarchive.Load(modulePath, version)
+		if err != nil {
+			return fmt.Errorf("could not load module from archive: %w", err)
+		}
+
+		tmpDir, err := os.MkdirTemp("", "timoni-push-archive-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		if err := manifest.Extract(tmpDir); err != nil {
+			return fmt.Errorf("could not extract module from archive: %w", err)
+		}
+
+		modulePath = tmpDir
+	}
+
+	digest, err := ociClient.Push(ctx, url, modulePath, oci.Metadata{})
+	spin.Stop()
+	if err != nil {
+		return fmt.Errorf("could not push module: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("module pushed to %s digest %s", url, digest))
+
+	return nil
+}
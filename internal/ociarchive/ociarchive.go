@@ -0,0 +1,360 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ociarchive loads OCI module artifacts from a local tarball,
+// such as an OCI image layout or a docker save-compatible archive,
+// so that timoni can operate without reaching out to a container registry.
+package ociarchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Layer is a single blob referenced by a Manifest.
+type Layer struct {
+	MediaType string
+	Digest    string
+	Data      []byte
+}
+
+// Manifest describes the OCI artifact located in the archive, along with
+// the raw layer blobs needed to extract the module.
+type Manifest struct {
+	// Digest is the manifest digest, e.g. sha256:<hex>.
+	Digest string
+	// Tag is the tag the manifest was resolved from, if any.
+	Tag string
+	// Layers holds the module content layers, in the order they were
+	// declared in the manifest.
+	Layers []Layer
+}
+
+// descriptor mirrors the subset of the OCI content descriptor spec
+// needed to resolve a manifest and its layers from a tarball.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociIndex struct {
+	Manifests []descriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+type dockerSaveManifest struct {
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+	Config   string   `json:"Config"`
+}
+
+const ociRefAnnotation = "org.opencontainers.image.ref.name"
+
+// Load walks the tarball at archivePath and returns the module manifest
+// matching ref, which can be a tag (e.g. "1.0.0") or a digest
+// (e.g. "sha256:<hex>"). Digests are matched only in OCI image layouts;
+// in a docker save archive ref is matched against the RepoTags alone, so a
+// digest finds no image there. When ref is empty and the archive contains
+// a single manifest, that manifest is returned.
+func Load(archivePath, ref string) (*Manifest, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open archive %s: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	blobs := make(map[string][]byte)
+	var index *ociIndex
+	var dockerManifests []dockerSaveManifest
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive %s: %w", archivePath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", hdr.Name, err)
+		}
+
+		switch path.Clean(hdr.Name) {
+		case "index.json":
+			var idx ociIndex
+			if err := json.Unmarshal(data, &idx); err != nil {
+				return nil, fmt.Errorf("invalid index.json: %w", err)
+			}
+			index = &idx
+		case "manifest.json":
+			if err := json.Unmarshal(data, &dockerManifests); err != nil {
+				return nil, fmt.Errorf("invalid manifest.json: %w", err)
+			}
+		default:
+			// OCI image layout blobs are stored as blobs/<alg>/<hex>,
+			// docker save layers as <hex>/layer.tar or <hex>.tar. Each
+			// docker save layer directory also holds a "json" metadata
+			// file and a "VERSION" file alongside layer.tar; skip those
+			// so they don't overwrite the actual layer content under the
+			// same directory-derived key.
+			if isArchiveLayerEntry(hdr.Name) {
+				blobs[digestKeyFromPath(hdr.Name)] = data
+			}
+		}
+	}
+
+	switch {
+	case index != nil:
+		return loadFromImageLayout(index, blobs, ref)
+	case dockerManifests != nil:
+		return loadFromDockerSave(dockerManifests, blobs, ref)
+	default:
+		return nil, fmt.Errorf("no index.json or manifest.json found in %s", archivePath)
+	}
+}
+
+// Extract decompresses and untars each layer onto dir, the same content
+// layout a registry pull would produce.
+func (m *Manifest) Extract(dir string) error {
+	for _, layer := range m.Layers {
+		gzr, err := gzip.NewReader(bytes.NewReader(layer.Data))
+		if err != nil {
+			return fmt.Errorf("layer %s is not gzip compressed: %w", layer.Digest, err)
+		}
+
+		tr := tar.NewReader(gzr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("unable to read layer %s: %w", layer.Digest, err)
+			}
+
+			target, err := safeJoin(dir, hdr.Name)
+			if err != nil {
+				return fmt.Errorf("layer %s: %w", layer.Digest, err)
+			}
+
+			switch hdr.Typeflag {
+			case tar.TypeDir:
+				if err := os.MkdirAll(target, 0o755); err != nil {
+					return err
+				}
+			case tar.TypeReg:
+				if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+					return err
+				}
+				out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+				if err != nil {
+					return err
+				}
+				if _, err := io.Copy(out, tr); err != nil {
+					out.Close()
+					return err
+				}
+				out.Close()
+			}
+		}
+
+		if err := gzr.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// safeJoin joins dir and name, the way Extract places a tar entry on disk.
+// name is resolved as if rooted at dir, so ".." path segments (a tar-slip)
+// cannot climb above it: "../../etc/x" lands at <dir>/etc/x instead of
+// being written outside the intended output directory. A result that
+// still falls outside dir is rejected with an error.
+func safeJoin(dir, name string) (string, error) {
+	dir = filepath.Clean(dir)
+	target := filepath.Join(dir, filepath.Clean(string(filepath.Separator)+name))
+
+	if target != dir && !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid tar entry %q: escapes output directory", name)
+	}
+
+	return target, nil
+}
+
+// SiblingSignature returns the raw signature blob for the given manifest
+// digest, if the archive carries one as a "<digest>.sig" layer, as produced
+// by `cosign save`.
+func (m *Manifest) SiblingSignature(archivePath string) ([]byte, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open archive %s: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	want := strings.TrimPrefix(m.Digest, "sha256:") + ".sig"
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive %s: %w", archivePath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if path.Base(hdr.Name) == want {
+			return io.ReadAll(tr)
+		}
+	}
+
+	return nil, fmt.Errorf("no signature layer found for %s in %s", m.Digest, archivePath)
+}
+
+func loadFromImageLayout(index *ociIndex, blobs map[string][]byte, ref string) (*Manifest, error) {
+	for _, d := range index.Manifests {
+		tag := d.Annotations[ociRefAnnotation]
+		if ref != "" && ref != tag && ref != d.Digest {
+			continue
+		}
+
+		data, ok := blobs[digestKey(d.Digest)]
+		if !ok {
+			return nil, fmt.Errorf("manifest blob %s not found in archive", d.Digest)
+		}
+
+		var man ociManifest
+		if err := json.Unmarshal(data, &man); err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %w", d.Digest, err)
+		}
+
+		var layers []Layer
+		for _, l := range man.Layers {
+			lb, ok := blobs[digestKey(l.Digest)]
+			if !ok {
+				return nil, fmt.Errorf("layer blob %s not found in archive", l.Digest)
+			}
+			layers = append(layers, Layer{MediaType: l.MediaType, Digest: l.Digest, Data: lb})
+		}
+
+		return &Manifest{Digest: d.Digest, Tag: tag, Layers: layers}, nil
+	}
+
+	return nil, fmt.Errorf("no manifest matching %q found in archive", ref)
+}
+
+func loadFromDockerSave(manifests []dockerSaveManifest, blobs map[string][]byte, ref string) (*Manifest, error) {
+	for _, m := range manifests {
+		matched := ref == ""
+		for _, t := range m.RepoTags {
+			if t == ref || strings.HasSuffix(t, ":"+ref) {
+				matched = true
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		var layers []Layer
+		for _, l := range m.Layers {
+			data, ok := blobs[digestKeyFromPath(l)]
+			if !ok {
+				return nil, fmt.Errorf("layer %s not found in archive", l)
+			}
+			layers = append(layers, Layer{MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip", Data: data})
+		}
+
+		return &Manifest{Tag: ref, Layers: layers}, nil
+	}
+
+	return nil, fmt.Errorf("no image matching %q found in archive", ref)
+}
+
+// isArchiveLayerEntry reports whether a tar entry holds blob content worth
+// indexing: an OCI image layout blob (blobs/<alg>/<hex>), a docker save
+// layer (<hex>/layer.tar) or a flat <hex>.tar file. It excludes the
+// per-layer "json"/"VERSION" metadata files docker save also writes under
+// each <hex>/ directory.
+func isArchiveLayerEntry(name string) bool {
+	clean := path.Clean(name)
+	parts := strings.Split(clean, "/")
+
+	switch {
+	case len(parts) >= 3 && parts[0] == "blobs":
+		return true
+	case len(parts) == 2:
+		return path.Base(parts[1]) == "layer.tar"
+	case len(parts) == 1:
+		return path.Ext(clean) == ".tar"
+	default:
+		return false
+	}
+}
+
+// digestKey normalises an OCI digest (alg:hex) to the key used to index
+// blobs discovered while walking the tarball.
+func digestKey(digest string) string {
+	_, hex, found := strings.Cut(digest, ":")
+	if !found {
+		return digest
+	}
+	return hex
+}
+
+// digestKeyFromPath derives the blob map key for a tar entry name.
+//
+// OCI image layouts store blobs as blobs/<alg>/<hex>, so the hex digest is
+// the last path element. docker save archives store each layer under its
+// own <hex>/ directory (e.g. <hex>/layer.tar or <hex>/json), so the
+// directory component - not the generic "layer.tar" filename shared by
+// every layer in the archive - is what disambiguates them. Flat archives
+// fall back to the file name with its extension stripped.
+func digestKeyFromPath(name string) string {
+	clean := path.Clean(name)
+	parts := strings.Split(clean, "/")
+
+	if len(parts) >= 3 && parts[0] == "blobs" {
+		return parts[len(parts)-1]
+	}
+
+	if len(parts) == 2 {
+		return parts[0]
+	}
+
+	base := parts[len(parts)-1]
+	return strings.TrimSuffix(base, path.Ext(base))
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ociarchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTarEntry appends a regular file entry to tw.
+func writeTarEntry(t *testing.T, tw *tar.Writer, name string, data []byte) {
+	t.Helper()
+	if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(data)), Mode: 0o644}); err != nil {
+		t.Fatalf("unable to write header for %s: %v", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatalf("unable to write data for %s: %v", name, err)
+	}
+}
+
+// gzipLayer tars and gzips a single file under name, the way a real
+// image layer is stored.
+func gzipLayer(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	writeTarEntry(t, tw, name, data)
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unable to close tar writer: %v", err)
+	}
+
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := gw.Write(tarBuf.Bytes()); err != nil {
+		t.Fatalf("unable to write gzip data: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("unable to close gzip writer: %v", err)
+	}
+	return gzBuf.Bytes()
+}
+
+func TestLoadDockerSaveMultiLayer(t *testing.T) {
+	layer1 := gzipLayer(t, "file1.txt", []byte("layer one"))
+	layer2 := gzipLayer(t, "file2.txt", []byte("layer two"))
+
+	man, err := json.Marshal([]dockerSaveManifest{
+		{
+			RepoTags: []string{"docker.io/org/app:1.0.0"},
+			Layers:   []string{"aaa111/layer.tar", "bbb222/layer.tar"},
+			Config:   "config.json",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal manifest: %v", err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "archive.tar")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("unable to create archive: %v", err)
+	}
+
+	tw := tar.NewWriter(f)
+	writeTarEntry(t, tw, "manifest.json", man)
+	writeTarEntry(t, tw, "aaa111/VERSION", []byte("1.0"))
+	writeTarEntry(t, tw, "aaa111/json", []byte("{}"))
+	writeTarEntry(t, tw, "aaa111/layer.tar", layer1)
+	writeTarEntry(t, tw, "bbb222/VERSION", []byte("1.0"))
+	writeTarEntry(t, tw, "bbb222/json", []byte("{}"))
+	writeTarEntry(t, tw, "bbb222/layer.tar", layer2)
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unable to close tar writer: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("unable to close archive: %v", err)
+	}
+
+	manifest, err := Load(archivePath, "1.0.0")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(manifest.Layers) != 2 {
+		t.Fatalf("got %d layers, want 2", len(manifest.Layers))
+	}
+	if bytes.Equal(manifest.Layers[0].Data, manifest.Layers[1].Data) {
+		t.Fatalf("layers resolved to the same blob, the docker save keys collided")
+	}
+	if !bytes.Equal(manifest.Layers[0].Data, layer1) {
+		t.Fatalf("first layer did not resolve to aaa111/layer.tar")
+	}
+	if !bytes.Equal(manifest.Layers[1].Data, layer2) {
+		t.Fatalf("second layer did not resolve to bbb222/layer.tar")
+	}
+}
+
+func TestManifestExtractContainsPathTraversal(t *testing.T) {
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	writeTarEntry(t, tw, "../../etc/cron.d/evil", []byte("malicious"))
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unable to close tar writer: %v", err)
+	}
+
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := gw.Write(tarBuf.Bytes()); err != nil {
+		t.Fatalf("unable to write gzip data: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("unable to close gzip writer: %v", err)
+	}
+
+	manifest := &Manifest{
+		Digest: "sha256:deadbeef",
+		Layers: []Layer{{Digest: "sha256:deadbeef", Data: gzBuf.Bytes()}},
+	}
+
+	dir := t.TempDir()
+	if err := manifest.Extract(dir); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(filepath.Dir(dir), "etc", "cron.d", "evil")); statErr == nil {
+		t.Fatalf("layer entry escaped the output directory")
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "etc", "cron.d", "evil")); statErr != nil {
+		t.Fatalf("layer entry was not contained within the output directory: %v", statErr)
+	}
+}
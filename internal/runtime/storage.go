@@ -0,0 +1,158 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package runtime talks to the Kubernetes API server on behalf of the
+// timoni CLI, resolving the cluster inventory of instances managed by
+// timoni and watching it for changes.
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+const (
+	// managedByLabelKey marks the ConfigMaps that hold a timoni instance
+	// inventory, as opposed to unrelated ConfigMaps in the same namespace.
+	managedByLabelKey = "app.kubernetes.io/managed-by"
+	managedByLabelVal = "timoni"
+	// instanceDataKey is the ConfigMap data key under which the inventory
+	// instance is stored as JSON.
+	instanceDataKey = "instance"
+)
+
+// ResourceManager holds the Kubernetes client used to read the timoni
+// inventory from the cluster.
+type ResourceManager struct {
+	client kubernetes.Interface
+}
+
+// NewResourceManager builds a ResourceManager from the kubectl-style
+// connection flags shared by the timoni commands.
+func NewResourceManager(rcg genericclioptions.RESTClientGetter) (*ResourceManager, error) {
+	cfg, err := rcg.ToRESTConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build kubeconfig: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Kubernetes client: %w", err)
+	}
+
+	return &ResourceManager{client: client}, nil
+}
+
+// StorageManager resolves the timoni instance inventory, which is stored
+// as one ConfigMap per instance.
+type StorageManager struct {
+	rm *ResourceManager
+}
+
+// NewStorageManager returns a StorageManager backed by rm.
+func NewStorageManager(rm *ResourceManager) *StorageManager {
+	return &StorageManager{rm: rm}
+}
+
+// List returns the instances found in ns, or in every namespace when ns is
+// empty, optionally narrowed down to those subject to bundleName.
+func (s *StorageManager) List(ctx context.Context, ns, bundleName string) ([]*apiv1.Instance, error) {
+	list, err := s.rm.client.CoreV1().ConfigMaps(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: inventorySelector(bundleName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list instances: %w", err)
+	}
+
+	instances := make([]*apiv1.Instance, 0, len(list.Items))
+	for i := range list.Items {
+		inv, err := instanceFromConfigMap(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, inv)
+	}
+
+	return instances, nil
+}
+
+// Watch opens a watch on the instances found in ns, or in every namespace
+// when ns is empty, optionally narrowed down to those subject to
+// bundleName. The caller is responsible for calling Stop on the returned
+// watch.Interface.
+func (s *StorageManager) Watch(ctx context.Context, ns, bundleName string) (watch.Interface, error) {
+	w, err := s.rm.client.CoreV1().ConfigMaps(ns).Watch(ctx, metav1.ListOptions{
+		LabelSelector: inventorySelector(bundleName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to watch instances: %w", err)
+	}
+
+	return w, nil
+}
+
+// InstanceFromObject converts obj, as received from a StorageManager
+// watch event, to an instance. It returns an error if obj is not a
+// ConfigMap holding a valid instance.
+func InstanceFromObject(obj interface{}) (*apiv1.Instance, error) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return nil, fmt.Errorf("unexpected watch object type %T", obj)
+	}
+
+	return instanceFromConfigMap(cm)
+}
+
+// instanceFromConfigMap decodes the instance stored in cm's data.
+func instanceFromConfigMap(cm *corev1.ConfigMap) (*apiv1.Instance, error) {
+	data, ok := cm.Data[instanceDataKey]
+	if !ok {
+		return nil, apierrors.NewNotFound(
+			corev1.Resource("configmaps"), cm.Name)
+	}
+
+	var inv apiv1.Instance
+	if err := json.Unmarshal([]byte(data), &inv); err != nil {
+		return nil, fmt.Errorf("invalid instance stored in %s/%s: %w", cm.Namespace, cm.Name, err)
+	}
+
+	inv.Name = cm.Name
+	inv.Namespace = cm.Namespace
+	inv.Labels = cm.Labels
+
+	return &inv, nil
+}
+
+// inventorySelector builds the label selector matching the ConfigMaps
+// that hold a timoni instance inventory, optionally narrowed down to
+// those subject to bundleName.
+func inventorySelector(bundleName string) string {
+	sel := fmt.Sprintf("%s=%s", managedByLabelKey, managedByLabelVal)
+	if bundleName != "" {
+		sel = fmt.Sprintf("%s,%s=%s", sel, apiv1.BundleNameLabelKey, bundleName)
+	}
+	return sel
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// VerifyArchive checks a signature blob resolved from a sibling layer in a
+// local archive (e.g. produced by `cosign save`) against the module digest,
+// without contacting a registry or the Rekor transparency log.
+func VerifyArchive(log logr.Logger,
+	provider string,
+	digest string,
+	signatureBlob []byte,
+	cosignKey string) error {
+
+	switch provider {
+	case "cosign":
+		return verifyCosignArchive(log, digest, signatureBlob, cosignKey)
+	default:
+		return fmt.Errorf("unsupported verification provider %q, must be: cosign", provider)
+	}
+}
+
+func verifyCosignArchive(log logr.Logger, digest string, signatureBlob []byte, cosignKey string) error {
+	if cosignKey == "" {
+		return fmt.Errorf("cosign-key is required to verify a signature loaded from an archive")
+	}
+
+	verifier, err := signature.LoadPublicKeyRaw([]byte(cosignKey))
+	if err != nil {
+		return fmt.Errorf("invalid cosign public key: %w", err)
+	}
+
+	log.Info("verifying archive signature with cosign")
+
+	if err := cosign.VerifyBlobSignature(context.Background(), verifier, []byte(digest), signatureBlob); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"sigs.k8s.io/yaml"
+)
+
+// AttestationPolicy describes the in-toto/SLSA provenance predicates a
+// module artifact must satisfy before it is extracted.
+type AttestationPolicy struct {
+	// Builder is the expected SLSA provenance builder.id, e.g.
+	// "https://github.com/org/repo/.github/workflows/release.yml@refs/tags/v1".
+	Builder string `json:"builder,omitempty"`
+	// SourceRepository is the expected source repository URI recorded in
+	// the provenance predicate's invocation config source.
+	SourceRepository string `json:"sourceRepository,omitempty"`
+}
+
+// LoadAttestationPolicy reads an attestation policy from a YAML or JSON
+// file.
+func LoadAttestationPolicy(path string) (*AttestationPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read attestation policy %s: %w", path, err)
+	}
+
+	var policy AttestationPolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid attestation policy %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+// inTotoStatement is the subset of an in-toto attestation statement needed
+// to evaluate a SLSA provenance predicate.
+type inTotoStatement struct {
+	PredicateType string `json:"predicateType"`
+	Predicate     struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Invocation struct {
+			ConfigSource struct {
+				URI string `json:"uri"`
+			} `json:"configSource"`
+		} `json:"invocation"`
+	} `json:"predicate"`
+}
+
+// EvaluateAttestationPolicy fetches the in-toto attestations attached to
+// the artifact at url, discards any that don't pass cosign signature
+// verification using the same key/keyless options as --verify cosign, and
+// verifies that at least one of the remaining ones was built by
+// policy.Builder from policy.SourceRepository. It must be called before
+// the artifact is extracted, so that a policy violation aborts the pull.
+func EvaluateAttestationPolicy(log logr.Logger,
+	url string,
+	policy *AttestationPolicy,
+	cosignKey string,
+	certificateIdentity string,
+	certificateIdentityRegexp string,
+	certificateOidcIssuer string,
+	certificateOidcIssuerRegexp string) error {
+
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return fmt.Errorf("invalid artifact reference: %w", err)
+	}
+
+	opts, err := cosignVerifyOptions(cosignKey, certificateIdentity, certificateIdentityRegexp,
+		certificateOidcIssuer, certificateOidcIssuerRegexp)
+	if err != nil {
+		return err
+	}
+
+	log.Info("evaluating attestation policy")
+
+	statements, err := fetchInTotoStatements(ref, opts)
+	if err != nil {
+		return fmt.Errorf("unable to fetch verified attestations: %w", err)
+	}
+
+	for _, st := range statements {
+		if policy.Builder != "" && st.Predicate.Builder.ID != policy.Builder {
+			continue
+		}
+		if policy.SourceRepository != "" && st.Predicate.Invocation.ConfigSource.URI != policy.SourceRepository {
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no attestation satisfies the configured attestation policy")
+}
+
+// fetchInTotoStatements returns the in-toto statements attached to ref
+// that pass cosign signature verification under opts. An attestation that
+// fails verification (unsigned, signed by an untrusted identity, etc.) is
+// dropped rather than evaluated, so a policy can only be satisfied by a
+// verified attestation.
+func fetchInTotoStatements(ref name.Reference, opts *cosign.CheckOpts) ([]inTotoStatement, error) {
+	verified, _, err := cosign.VerifyImageAttestations(context.Background(), ref, opts)
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	var statements []inTotoStatement
+	for _, s := range verified {
+		payload, err := cosign.AttestationToPayload(s)
+		if err != nil {
+			continue
+		}
+
+		var st inTotoStatement
+		if err := json.Unmarshal(payload, &st); err != nil {
+			continue
+		}
+		statements = append(statements, st)
+	}
+
+	return statements, nil
+}
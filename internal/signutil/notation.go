@@ -0,0 +1,60 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/notaryproject/notation-go"
+	"github.com/notaryproject/notation-go/dir"
+	"github.com/notaryproject/notation-go/registry"
+	"github.com/notaryproject/notation-go/verifier"
+	"github.com/notaryproject/notation-go/verifier/trustpolicy"
+	"github.com/notaryproject/notation-go/verifier/truststore"
+)
+
+// verifyNotation validates a Notary Project (notation-go) signature
+// attached to the OCI artifact at url, using the trust policy and trust
+// store configured in the user's notation config directory, the same
+// locations the `notation` CLI reads from.
+func verifyNotation(log logr.Logger, url string) error {
+	policyDoc, err := trustpolicy.LoadDocument()
+	if err != nil {
+		return fmt.Errorf("unable to load notation trust policy: %w", err)
+	}
+
+	v, err := verifier.New(policyDoc, truststore.NewX509TrustStore(dir.ConfigFS()), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create notation verifier: %w", err)
+	}
+
+	repo, err := registry.NewRepository(url)
+	if err != nil {
+		return fmt.Errorf("invalid artifact url: %w", err)
+	}
+
+	log.Info("verifying signature with notation")
+
+	opts := notation.VerifyOptions{ArtifactReference: url, MaxSignatureAttempts: 10}
+	if _, _, err := notation.Verify(context.Background(), v, repo, opts); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	return nil
+}
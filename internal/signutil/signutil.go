@@ -0,0 +1,119 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package signutil verifies the authenticity of OCI artifacts pulled by
+// timoni, dispatching to the configured signature provider.
+package signutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// Verify checks that the artifact at url is signed according to provider,
+// which must be "cosign" or "notation". The keyed cosign flow is used when
+// cosignKey is set, otherwise keyless (Fulcio/Rekor) verification is
+// attempted using the supplied certificate identity and OIDC issuer
+// constraints. The notation provider ignores the cosign-specific
+// parameters and validates a Notary Project signature instead.
+func Verify(log logr.Logger,
+	provider string,
+	url string,
+	cosignKey string,
+	certificateIdentity string,
+	certificateIdentityRegexp string,
+	certificateOidcIssuer string,
+	certificateOidcIssuerRegexp string) error {
+
+	switch provider {
+	case "cosign":
+		return verifyCosign(log, url, cosignKey, certificateIdentity, certificateIdentityRegexp,
+			certificateOidcIssuer, certificateOidcIssuerRegexp)
+	case "notation":
+		return verifyNotation(log, url)
+	default:
+		return fmt.Errorf("unsupported verification provider %q, must be one of: cosign, notation", provider)
+	}
+}
+
+func verifyCosign(log logr.Logger,
+	url string,
+	cosignKey string,
+	certificateIdentity string,
+	certificateIdentityRegexp string,
+	certificateOidcIssuer string,
+	certificateOidcIssuerRegexp string) error {
+
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return fmt.Errorf("invalid artifact reference: %w", err)
+	}
+
+	opts, err := cosignVerifyOptions(cosignKey, certificateIdentity, certificateIdentityRegexp,
+		certificateOidcIssuer, certificateOidcIssuerRegexp)
+	if err != nil {
+		return err
+	}
+
+	log.Info("verifying signature with cosign")
+
+	if _, _, err := cosign.VerifyImageSignatures(context.Background(), ref, opts); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	return nil
+}
+
+// cosignVerifyOptions builds the cosign check options for either the keyed
+// or the keyless (Fulcio/Rekor) verification flow.
+func cosignVerifyOptions(cosignKey string,
+	certificateIdentity string,
+	certificateIdentityRegexp string,
+	certificateOidcIssuer string,
+	certificateOidcIssuerRegexp string) (*cosign.CheckOpts, error) {
+
+	opts := &cosign.CheckOpts{
+		IgnoreTlog: cosignKey != "",
+	}
+
+	if cosignKey != "" {
+		verifier, err := signature.LoadPublicKeyRaw([]byte(cosignKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cosign public key: %w", err)
+		}
+		opts.SigVerifier = verifier
+		return opts, nil
+	}
+
+	if certificateIdentity == "" && certificateIdentityRegexp == "" {
+		return nil, fmt.Errorf("certificate-identity or certificate-identity-regexp is required for keyless verification")
+	}
+	if certificateOidcIssuer == "" && certificateOidcIssuerRegexp == "" {
+		return nil, fmt.Errorf("certificate-oidc-issuer or certificate-oidc-issuer-regexp is required for keyless verification")
+	}
+
+	opts.CertIdentity = certificateIdentity
+	opts.CertIdentityRegexp = certificateIdentityRegexp
+	opts.CertOidcIssuer = certificateOidcIssuer
+	opts.CertOidcIssuerRegexp = certificateOidcIssuerRegexp
+
+	return opts, nil
+}